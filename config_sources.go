@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceConfig 描述一个扫描数据源，sources.json/sources.yaml 里的每一项都对应一个 Source 实现
+type SourceConfig struct {
+	ID          string            `json:"id" yaml:"id"`
+	Type        string            `json:"type" yaml:"type"` // local | smb | webdav | s3
+	Addr        string            `json:"addr" yaml:"addr"` // SMB/WebDAV/S3 的 host:port 或 URL
+	Share       string            `json:"share" yaml:"share"` // SMB共享名或S3桶名
+	Root        string            `json:"root" yaml:"root"`   // 本地路径、SMB/WebDAV 起始目录或 S3 前缀
+	Credentials map[string]string `json:"credentials" yaml:"credentials"`
+}
+
+// loadSources 读取数据源配置文件，取代旧版 config.env 里单一的 path 字段，
+// 一次性支持多个 NAS 共享/对象存储同时索引；按扩展名选择 JSON 或 YAML 解析
+func loadSources(path string) ([]SourceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据源配置失败: %v", err)
+	}
+
+	var sources []SourceConfig
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		if err := yaml.Unmarshal(data, &sources); err != nil {
+			return nil, fmt.Errorf("解析数据源配置(yaml)失败: %v", err)
+		}
+	} else {
+		if err := json.Unmarshal(data, &sources); err != nil {
+			return nil, fmt.Errorf("解析数据源配置(json)失败: %v", err)
+		}
+	}
+	return sources, nil
+}
+
+// newSource 根据 SourceConfig.Type 构造对应的 Source 实现
+func newSource(cfg SourceConfig) (Source, error) {
+	switch cfg.Type {
+	case "local", "":
+		return newLocalSource(cfg.ID, cfg.Root), nil
+	case "smb":
+		return newSMBSource(cfg), nil
+	case "webdav":
+		return newWebDAVSource(cfg), nil
+	case "s3":
+		return newS3Source(cfg)
+	default:
+		return nil, fmt.Errorf("不支持的数据源类型: %s", cfg.Type)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fingerprintChunk 是首尾指纹各自读取的字节数，避免对大体积媒体文件做整文件哈希
+const fingerprintChunk = 64 * 1024
+
+// dupKey 是去重分组的键：相同 size 下再用首尾+总大小的组合指纹二次确认
+type dupKey struct {
+	fingerprint string
+	size        int64
+}
+
+// DuplicateGroup 是 /duplicates 返回的一组重复文件
+type DuplicateGroup struct {
+	ID          int64      `json:"id"`
+	Fingerprint string     `json:"fingerprint"`
+	Count       int        `json:"count"`
+	TotalBytes  int64      `json:"total_bytes"`
+	WastedBytes int64      `json:"wasted_bytes"`
+	Members     []FileInfo `json:"members"`
+}
+
+// fileFingerprint 计算文件首 64KiB、尾 64KiB 及总大小的组合哈希，用来在同一 size 分组内
+// 二次确认是否真的是重复文件，而不必像 sha1 字段那样读完整个文件
+func fileFingerprint(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, fingerprintChunk)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	tail := make([]byte, fingerprintChunk)
+	tailStart := size - fingerprintChunk
+	if tailStart < 0 {
+		tailStart = 0
+	}
+	if _, err := f.Seek(tailStart, io.SeekStart); err != nil {
+		return "", err
+	}
+	n, err = io.ReadFull(f, tail)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	tail = tail[:n]
+
+	h := sha256.New()
+	h.Write(head)
+	h.Write(tail)
+	fmt.Fprintf(h, ":%d", size)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// computeDuplicates 按 size 分组后用三段式指纹二次确认，重建 dup_groups/dup_members；
+// 只处理本地可读路径下的文件，SMB/WebDAV/S3 等数据源暂不支持指纹读取
+func computeDuplicates(dbPath string) error {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sizeRows, err := db.Query("SELECT size FROM files WHERE size > 0 GROUP BY size HAVING COUNT(*) > 1")
+	if err != nil {
+		return err
+	}
+	var sizes []int64
+	for sizeRows.Next() {
+		var size int64
+		if err := sizeRows.Scan(&size); err != nil {
+			sizeRows.Close()
+			return err
+		}
+		sizes = append(sizes, size)
+	}
+	sizeRows.Close()
+
+	groups := make(map[dupKey][]int64)
+	for _, size := range sizes {
+		rows, err := db.Query(`
+			SELECT f.id, p.path, f.filename, p.source_id
+			FROM files f
+			JOIN paths p ON f.path_id = p.id
+			WHERE f.size = ?`, size)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			var dir, filename, sourceID string
+			if err := rows.Scan(&id, &dir, &filename, &sourceID); err != nil {
+				rows.Close()
+				return err
+			}
+			if sourceID != "local" && sourceID != "" {
+				// 非本地数据源没有本地文件句柄可读，跳过指纹比对
+				continue
+			}
+			fp, err := fileFingerprint(filepath.Join(dir, filename), size)
+			if err != nil {
+				log.Printf("计算去重指纹失败 %s/%s: %v\n", dir, filename, err)
+				continue
+			}
+			key := dupKey{fingerprint: fp, size: size}
+			groups[key] = append(groups[key], id)
+		}
+		rows.Close()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM dup_members"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM dup_groups"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var totalGroups int
+	for key, ids := range groups {
+		if len(ids) < 2 {
+			continue
+		}
+		res, err := tx.Exec(
+			"INSERT INTO dup_groups (fingerprint, size, count, total_bytes) VALUES (?, ?, ?, ?)",
+			key.fingerprint, key.size, len(ids), key.size*int64(len(ids)),
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		groupID, _ := res.LastInsertId()
+		for _, id := range ids {
+			if _, err := tx.Exec("INSERT INTO dup_members (group_id, file_id) VALUES (?, ?)", groupID, id); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		totalGroups++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("重复文件扫描完成，共 %d 组\n", totalGroups)
+	return nil
+}
+
+// handleDuplicates 处理 GET /duplicates，按浪费空间（(count-1)*size）降序返回重复文件分组；
+// 加上 rescan=1 会先同步重新计算一遍再返回，供用户手动触发
+func handleDuplicates(dbPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("rescan") == "1" {
+			if err := computeDuplicates(dbPath); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		groupRows, err := db.Query("SELECT id, fingerprint, count, total_bytes FROM dup_groups")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer groupRows.Close()
+
+		var groups []DuplicateGroup
+		for groupRows.Next() {
+			var g DuplicateGroup
+			if err := groupRows.Scan(&g.ID, &g.Fingerprint, &g.Count, &g.TotalBytes); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if g.Count > 0 {
+				g.WastedBytes = g.TotalBytes - g.TotalBytes/int64(g.Count)
+			}
+			groups = append(groups, g)
+		}
+
+		for i := range groups {
+			memberRows, err := db.Query(`
+				SELECT p.path, f.filename, f.size, f.create_time, p.source_id
+				FROM dup_members dm
+				JOIN files f ON dm.file_id = f.id
+				JOIN paths p ON f.path_id = p.id
+				WHERE dm.group_id = ?`, groups[i].ID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for memberRows.Next() {
+				var fi FileInfo
+				if err := memberRows.Scan(&fi.Path, &fi.FileName, &fi.Size, &fi.CreateTime, &fi.SourceID); err != nil {
+					memberRows.Close()
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				groups[i].Members = append(groups[i].Members, fi)
+			}
+			memberRows.Close()
+		}
+
+		sort.Slice(groups, func(i, j int) bool { return groups[i].WastedBytes > groups[j].WastedBytes })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(groups)
+	}
+}
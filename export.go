@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportPageSize 是每一页导出的条目数，思路和 sitemap 分片一样：固定大小，
+// 方便下游工具（移动端、外部搜索界面、备份校验）分页拉取、按页比对差异
+const exportPageSize = 10000
+
+// ExportEntry 是导出页面里的单条记录
+type ExportEntry struct {
+	Path       string `json:"path" xml:"path"`
+	FileName   string `json:"filename" xml:"filename"`
+	Size       int64  `json:"size" xml:"size"`
+	CreateTime string `json:"create_time" xml:"create_time"`
+	SourceID   string `json:"source_id" xml:"source_id"`
+}
+
+// ExportPage 是 /export?page=N 返回的单页内容
+type ExportPage struct {
+	XMLName xml.Name      `json:"-" xml:"page"`
+	Page    int           `json:"page" xml:"page,attr"`
+	Entries []ExportEntry `json:"entries" xml:"entry"`
+}
+
+// ExportIndexEntry 描述索引文档里的一页
+type ExportIndexEntry struct {
+	Page         int    `json:"page" xml:"page"`
+	URL          string `json:"url" xml:"url"`
+	LastModified string `json:"last_modified" xml:"last_modified"`
+}
+
+// ExportIndex 类似 sitemap index：列出所有分页及其最后修改时间，不用先拉全量数据就能知道有多少页
+type ExportIndex struct {
+	XMLName    xml.Name           `json:"-" xml:"export_index"`
+	TotalPages int                `json:"total_pages" xml:"total_pages"`
+	Pages      []ExportIndexEntry `json:"pages" xml:"page_ref"`
+}
+
+// latestScanFinish 返回 scans 表里最近一次成功扫描的完成时间，用于 If-Modified-Since 判断
+func latestScanFinish(db *sql.DB) (time.Time, error) {
+	var finishedAt string
+	err := db.QueryRow("SELECT finished_at FROM scans WHERE status = 'ok' ORDER BY finished_at DESC LIMIT 1").Scan(&finishedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, finishedAt)
+}
+
+// exportSnapshotDir 以最近一次扫描完成时间命名快照目录，同一轮扫描内的多次导出请求复用同一批分页文件
+func exportSnapshotDir(exeDir string, finishedAt time.Time) string {
+	return filepath.Join(exeDir, "snapshots", finishedAt.UTC().Format("20060102T150405Z"))
+}
+
+// handleExport 处理 /export?format=json|ndjson|xml&page=N；不带 page 参数时返回索引文档
+func handleExport(dbPath, exeDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "ndjson" && format != "xml" {
+			http.Error(w, "不支持的导出格式", http.StatusBadRequest)
+			return
+		}
+
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		finishedAt, err := latestScanFinish(db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if finishedAt.IsZero() {
+			http.Error(w, "索引尚未完成首次扫描", http.StatusServiceUnavailable)
+			return
+		}
+
+		if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+			if t, err := http.ParseTime(ims); err == nil && !finishedAt.After(t) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		snapshotDir := exportSnapshotDir(exeDir, finishedAt)
+		if err := os.MkdirAll(snapshotDir, 0o755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var total int
+		if err := db.QueryRow("SELECT COUNT(*) FROM files").Scan(&total); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		totalPages := (total + exportPageSize - 1) / exportPageSize
+		if totalPages == 0 {
+			totalPages = 1
+		}
+
+		w.Header().Set("Last-Modified", finishedAt.UTC().Format(http.TimeFormat))
+
+		pageParam := r.URL.Query().Get("page")
+		if pageParam == "" {
+			index := ExportIndex{TotalPages: totalPages}
+			for p := 0; p < totalPages; p++ {
+				index.Pages = append(index.Pages, ExportIndexEntry{
+					Page:         p,
+					URL:          fmt.Sprintf("/export?format=%s&page=%d", format, p),
+					LastModified: finishedAt.UTC().Format(time.RFC3339),
+				})
+			}
+			data, err := encodeExport(format, index, nil)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			serveExportBytes(w, r, format, data)
+			return
+		}
+
+		page, err := strconv.Atoi(pageParam)
+		if err != nil || page < 0 || page >= totalPages {
+			http.Error(w, "无效的分页参数", http.StatusBadRequest)
+			return
+		}
+
+		cachePath := filepath.Join(snapshotDir, fmt.Sprintf("page-%d.%s", page, format))
+		if data, err := os.ReadFile(cachePath); err == nil {
+			serveExportBytes(w, r, format, data)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT p.path, f.filename, f.size, f.create_time, p.source_id
+			FROM files f
+			JOIN paths p ON f.path_id = p.id
+			ORDER BY f.id
+			LIMIT ? OFFSET ?`, exportPageSize, page*exportPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		var entries []ExportEntry
+		for rows.Next() {
+			var e ExportEntry
+			if err := rows.Scan(&e.Path, &e.FileName, &e.Size, &e.CreateTime, &e.SourceID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, e)
+		}
+
+		data, err := encodeExport(format, ExportPage{Page: page, Entries: entries}, entries)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+			log.Println("写入导出快照失败:", err)
+		}
+
+		serveExportBytes(w, r, format, data)
+	}
+}
+
+// encodeExport 按格式编码导出内容；ndjson 时 entries 非空则逐行编码，否则退回普通 json
+func encodeExport(format string, v interface{}, entries []ExportEntry) ([]byte, error) {
+	switch format {
+	case "xml":
+		return xml.MarshalIndent(v, "", "  ")
+	case "ndjson":
+		if entries == nil {
+			return json.Marshal(v)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// serveExportBytes 设置格式对应的 Content-Type，并在客户端支持时做 gzip 压缩
+func serveExportBytes(w http.ResponseWriter, r *http.Request, format string, data []byte) {
+	switch format {
+	case "xml":
+		w.Header().Set("Content-Type", "application/xml")
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write(data)
+		return
+	}
+	w.Write(data)
+}
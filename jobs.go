@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Subscription 对应 subscriptions 表的一行：某个已配置数据源按某个 cron 表达式的定时扫描任务，
+// 取代旧版 main() 里写死的单一 time=HH:MM 定时任务
+type Subscription struct {
+	ID           int64  `json:"id"`
+	Alias        string `json:"alias"`
+	SourceID     string `json:"source_id"`
+	RootPath     string `json:"root_path"`
+	CronExpr     string `json:"cron_expr"`
+	Status       string `json:"status"`
+	LastRun      string `json:"last_run,omitempty"`
+	LastDuration int64  `json:"last_duration"`
+	LastError    string `json:"last_error,omitempty"`
+	LogPath      string `json:"log_path,omitempty"`
+}
+
+// jobRunner 负责实际执行 subscription、记录日志、通过 cancels 中断正在运行的任务，以及
+// 通过 entries 跟踪每个 subscription 注册在 scheduler 里的 cron.EntryID，供 REST 接口
+// 新建/更新/删除 subscription 时同步增删调度器里的任务，而不只是改 DB 行。
+// 旧版 scanAndSave 是一个不可中断的阻塞调用，这里用 context.Context + sync.Map 补上取消能力
+type jobRunner struct {
+	cancels   sync.Map // map[int64]context.CancelFunc
+	entries   sync.Map // map[int64]cron.EntryID
+	scheduler *cron.Cron
+	dbPath    string
+	exeDir    string
+	sources   map[string]Source
+}
+
+func newJobRunner(dbPath, exeDir string, sources []Source) *jobRunner {
+	bySourceID := make(map[string]Source, len(sources))
+	for _, s := range sources {
+		bySourceID[s.ID()] = s
+	}
+	return &jobRunner{dbPath: dbPath, exeDir: exeDir, sources: bySourceID}
+}
+
+func (jr *jobRunner) logPath(sub Subscription) string {
+	return filepath.Join(jr.exeDir, "logs", fmt.Sprintf("job-%d.log", sub.ID))
+}
+
+// run 执行一次 subscription 对应数据源的扫描，追加写入 job 专属日志文件，
+// 结束时写一行“##执行结束.. <timestamp>, 耗时N秒”风格的 footer 并记录扫描/变更的行数；
+// 扫描本身经 recordScan 包一层，写入 scans 表并触发重复文件检测，而不只是首次启动才有
+func (jr *jobRunner) run(sub Subscription) {
+	src, ok := jr.sources[sub.SourceID]
+	if !ok {
+		log.Printf("任务 %s 引用的数据源 %s 不存在\n", sub.Alias, sub.SourceID)
+		jr.finish(sub.ID, "error", "数据源不存在", 0, "")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jr.cancels.Store(sub.ID, cancel)
+	defer jr.cancels.Delete(sub.ID)
+
+	logFilePath := jr.logPath(sub)
+	if err := os.MkdirAll(filepath.Dir(logFilePath), 0o755); err != nil {
+		log.Println("创建任务日志目录失败:", err)
+	}
+	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Println("打开任务日志失败:", err)
+		return
+	}
+	defer logFile.Close()
+
+	started := time.Now()
+	fmt.Fprintf(logFile, "## 开始执行 %s, %s\n", sub.Alias, started.Format(time.RFC3339))
+	jr.setStatus(sub.ID, "running")
+
+	scanned, changed, scanErr := recordScan(jr.dbPath, func() (int64, int64, error) {
+		return scanSource(ctx, src, jr.dbPath)
+	})
+
+	duration := time.Since(started)
+	status := "ok"
+	errMsg := ""
+	if scanErr != nil {
+		status = "error"
+		errMsg = scanErr.Error()
+	}
+
+	fmt.Fprintf(logFile, "##执行结束.. %s, 耗时%.0f秒, 扫描%d个文件, %d个有变化\n",
+		time.Now().Format(time.RFC3339), duration.Seconds(), scanned, changed)
+
+	jr.finish(sub.ID, status, errMsg, duration, logFilePath)
+}
+
+func (jr *jobRunner) setStatus(id int64, status string) {
+	db, err := sql.Open("sqlite", jr.dbPath)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+	db.Exec("UPDATE subscriptions SET status = ? WHERE id = ?", status, id)
+}
+
+func (jr *jobRunner) finish(id int64, status, lastError string, duration time.Duration, logPath string) {
+	db, err := sql.Open("sqlite", jr.dbPath)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+	db.Exec(
+		"UPDATE subscriptions SET status = ?, last_run = ?, last_duration = ?, last_error = ?, log_path = ? WHERE id = ?",
+		status, time.Now().UTC().Format(time.RFC3339), int64(duration.Seconds()), lastError, logPath, id,
+	)
+}
+
+// stop 取消一个正在进行中的扫描；job 不存在或已结束时返回 false
+func (jr *jobRunner) stop(id int64) bool {
+	v, ok := jr.cancels.Load(id)
+	if !ok {
+		return false
+	}
+	v.(context.CancelFunc)()
+	return true
+}
+
+// scheduleAll 把 subscriptions 表里所有任务装进一个 cron 调度器，并记录 jr 持有的
+// scheduler 引用，供 schedule/unschedule/reschedule 在之后新增/删除/更新任务时使用
+func (jr *jobRunner) scheduleAll(scheduler *cron.Cron) error {
+	jr.scheduler = scheduler
+
+	db, err := sql.Open("sqlite", jr.dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, alias, source_id, root_path, cron_expr FROM subscriptions")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.Alias, &sub.SourceID, &sub.RootPath, &sub.CronExpr); err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+
+	for _, sub := range subs {
+		if err := jr.schedule(sub); err != nil {
+			log.Printf("任务 %s 的cron表达式无效: %v\n", sub.Alias, err)
+		}
+	}
+	return nil
+}
+
+// schedule 把单个 subscription 注册进当前 cron 调度器，记录返回的 EntryID，
+// 供 unschedule/reschedule 之后能精确移除这一个任务而不影响其它任务
+func (jr *jobRunner) schedule(sub Subscription) error {
+	entryID, err := jr.scheduler.AddFunc(sub.CronExpr, func() { jr.run(sub) })
+	if err != nil {
+		return err
+	}
+	jr.entries.Store(sub.ID, entryID)
+	return nil
+}
+
+// unschedule 把一个 subscription 从调度器里移除，供 DELETE /jobs/{id} 以及
+// reschedule 更新 cron_expr 前的清理使用
+func (jr *jobRunner) unschedule(id int64) {
+	if v, ok := jr.entries.Load(id); ok {
+		jr.scheduler.Remove(v.(cron.EntryID))
+		jr.entries.Delete(id)
+	}
+}
+
+// reschedule 先移除旧的 EntryID 再按最新的 cron_expr 重新注册，供 PUT /jobs/{id}
+// 更新任务后让调度器立即按新的周期生效，而不是继续按旧周期跑一个引用已变的闭包
+func (jr *jobRunner) reschedule(sub Subscription) error {
+	jr.unschedule(sub.ID)
+	return jr.schedule(sub)
+}
+
+// seedDefaultSubscriptions 在 subscriptions 表为空时，把每个已配置的数据源按 legacyCron
+// 注册成一个默认任务，兼容旧版 config.env 里单一 time=HH:MM 字段的行为；root_path 取自
+// SourceConfig.Root（本地路径、SMB/WebDAV 起始目录或 S3 前缀），而不是数据源 ID
+func seedDefaultSubscriptions(dbPath, legacyCron string, sourceConfigs []SourceConfig) error {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM subscriptions").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	for _, cfg := range sourceConfigs {
+		if _, err := db.Exec(
+			"INSERT INTO subscriptions (alias, source_id, root_path, cron_expr, status) VALUES (?, ?, ?, ?, 'idle')",
+			cfg.ID, cfg.ID, cfg.Root, legacyCron,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
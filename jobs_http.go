@@ -0,0 +1,207 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// handleJobsCollection 处理 GET /jobs（列出全部任务）和 POST /jobs（新建任务）；
+// POST 在写库后立即调用 jr.schedule 让新任务进入当前 cron 调度器，而不必等进程重启
+func handleJobsCollection(dbPath string, jr *jobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		switch r.Method {
+		case http.MethodGet:
+			rows, err := db.Query(`
+				SELECT id, alias, source_id, root_path, cron_expr, status,
+				       COALESCE(last_run, ''), last_duration, COALESCE(last_error, ''), COALESCE(log_path, '')
+				FROM subscriptions`)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer rows.Close()
+
+			var subs []Subscription
+			for rows.Next() {
+				var s Subscription
+				if err := rows.Scan(&s.ID, &s.Alias, &s.SourceID, &s.RootPath, &s.CronExpr, &s.Status,
+					&s.LastRun, &s.LastDuration, &s.LastError, &s.LogPath); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				subs = append(subs, s)
+			}
+			json.NewEncoder(w).Encode(subs)
+
+		case http.MethodPost:
+			var sub Subscription
+			if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+				http.Error(w, "请求体解析失败", http.StatusBadRequest)
+				return
+			}
+			if sub.Alias == "" || sub.SourceID == "" || sub.CronExpr == "" {
+				http.Error(w, "缺少alias/source_id/cron_expr", http.StatusBadRequest)
+				return
+			}
+			res, err := db.Exec(
+				"INSERT INTO subscriptions (alias, source_id, root_path, cron_expr, status) VALUES (?, ?, ?, ?, 'idle')",
+				sub.Alias, sub.SourceID, sub.RootPath, sub.CronExpr,
+			)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			sub.ID, _ = res.LastInsertId()
+			sub.Status = "idle"
+			if err := jr.schedule(sub); err != nil {
+				db.Exec("DELETE FROM subscriptions WHERE id = ?", sub.ID)
+				http.Error(w, "cron表达式无效: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(sub)
+
+		default:
+			http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleJobItem 处理 /jobs/{id}、/jobs/{id}/run、/jobs/{id}/stop、/jobs/{id}/log；
+// PUT 更新 cron_expr 后调用 jr.reschedule，DELETE 删除前调用 jr.unschedule，
+// 确保调度器里的任务和 subscriptions 表保持一致
+func handleJobItem(dbPath string, jr *jobRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/jobs/")
+		parts := strings.SplitN(rest, "/", 2)
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			http.Error(w, "无效的任务ID", http.StatusBadRequest)
+			return
+		}
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+
+		db, err := sql.Open("sqlite", dbPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		switch action {
+		case "run":
+			if r.Method != http.MethodPost {
+				http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+				return
+			}
+			var sub Subscription
+			err := db.QueryRow("SELECT id, alias, source_id, root_path, cron_expr FROM subscriptions WHERE id = ?", id).
+				Scan(&sub.ID, &sub.Alias, &sub.SourceID, &sub.RootPath, &sub.CronExpr)
+			if err == sql.ErrNoRows {
+				http.Error(w, "任务不存在", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			go jr.run(sub)
+			w.WriteHeader(http.StatusAccepted)
+
+		case "stop":
+			if r.Method != http.MethodPost {
+				http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+				return
+			}
+			if !jr.stop(id) {
+				http.Error(w, "任务未在运行", http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+
+		case "log":
+			if r.Method != http.MethodGet {
+				http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+				return
+			}
+			var logPath string
+			if err := db.QueryRow("SELECT COALESCE(log_path, '') FROM subscriptions WHERE id = ?", id).Scan(&logPath); err != nil {
+				http.Error(w, "任务不存在", http.StatusNotFound)
+				return
+			}
+			if logPath == "" {
+				w.Write(nil)
+				return
+			}
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(data)
+
+		case "":
+			switch r.Method {
+			case http.MethodPut:
+				var sub Subscription
+				if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+					http.Error(w, "请求体解析失败", http.StatusBadRequest)
+					return
+				}
+				// 先校验 cron_expr 再落库：reschedule 会先取消旧的调度再注册新的，
+				// 如果等 UPDATE 提交之后才发现表达式无效，旧任务已经被取消、DB 也已经
+				// 写成了坏值，任务就悄悄停跑了
+				if _, err := cron.ParseStandard(sub.CronExpr); err != nil {
+					http.Error(w, "cron表达式无效: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				if _, err := db.Exec(
+					"UPDATE subscriptions SET alias = ?, source_id = ?, root_path = ?, cron_expr = ? WHERE id = ?",
+					sub.Alias, sub.SourceID, sub.RootPath, sub.CronExpr, id,
+				); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				sub.ID = id
+				if err := jr.reschedule(sub); err != nil {
+					// 理论上校验通过后这里不应该再失败；真出现时已经落库，只记日志，
+					// 调度器侧保留旧任务（reschedule 内部先 unschedule 再 schedule，
+					// 失败多半发生在 schedule 阶段，此时旧 entry 已被移除）
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+
+			case http.MethodDelete:
+				if _, err := db.Exec("DELETE FROM subscriptions WHERE id = ?", id); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				jr.unschedule(id)
+				w.WriteHeader(http.StatusOK)
+
+			default:
+				http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+			}
+
+		default:
+			http.Error(w, "未知的任务操作", http.StatusNotFound)
+		}
+	}
+}
@@ -1,27 +1,58 @@
 package main
 
 import (
+	"context"
+	"crypto/sha1"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 	"github.com/rs/cors" // 引入 CORS 库
 	"github.com/joho/godotenv"
 	"github.com/robfig/cron/v3"
+	"github.com/fsnotify/fsnotify"
+	"github.com/qadan72/Nas-Everything-search/search/fuzzy"
 	_ "modernc.org/sqlite"
 )
 
+// hashSizeCap 超过该大小的文件不计算 sha1，避免大文件拖慢增量扫描
+const hashSizeCap = 200 * 1024 * 1024 // 200MB
+
+// defaultLimit/maxFuzz 是 /get 分页与模糊匹配的默认上限
+const (
+	defaultLimit = 50
+	maxFuzz      = 2
+)
+
 type FileInfo struct {
 	Path       string `json:"path"`
 	FileName   string `json:"filename"`
 	Size       int64  `json:"size"`
 	CreateTime string `json:"create_time"`
+	SourceID   string `json:"source_id"`
+}
+
+// SearchHit 在 FileInfo 基础上附加排序用的综合得分
+type SearchHit struct {
+	FileInfo
+	Score float64 `json:"score"`
+}
+
+// SearchResponse 是 /get 返回的 JSON 信封：总命中数、耗时、分页后的结果
+type SearchResponse struct {
+	Total  int         `json:"total"`
+	TookMs int64       `json:"took_ms"`
+	Hits   []SearchHit `json:"hits"`
 }
 
 func initDB(dbPath string) error {
@@ -47,7 +78,8 @@ func initDB(dbPath string) error {
 	_, err = db.Exec(`
 		CREATE TABLE IF NOT EXISTS paths (
 			id INTEGER PRIMARY KEY,
-			path TEXT NOT NULL UNIQUE
+			path TEXT NOT NULL UNIQUE,
+			source_id TEXT NOT NULL DEFAULT 'local'
 		);
 		CREATE TABLE IF NOT EXISTS files (
 			id INTEGER PRIMARY KEY,
@@ -55,7 +87,10 @@ func initDB(dbPath string) error {
 			filename TEXT NOT NULL,
 			size INTEGER,
 			create_time DATETIME,
-			FOREIGN KEY(path_id) REFERENCES paths(id)
+			mtime DATETIME,
+			sha1 TEXT,
+			FOREIGN KEY(path_id) REFERENCES paths(id),
+			UNIQUE(path_id, filename)
 		);
 		CREATE INDEX IF NOT EXISTS idx_filename ON files(filename);
 	`)
@@ -63,6 +98,159 @@ func initDB(dbPath string) error {
 		return fmt.Errorf("创建表格失败: %v", err)
 	}
 
+	// 旧库升级：早期版本把二元分词结果塞进 files.bigrams 影子列，查询时对该列做 AND 连接的
+	// LIKE '%gram%'，在中日文件名上等于又做了一遍全表扫描；列本身不再写入，保留只是为了
+	// 不破坏旧库升级路径，真正的分词改存进下面的 file_bigrams 索引表
+	if _, err := db.Exec(`ALTER TABLE files ADD COLUMN bigrams TEXT`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("添加bigrams列失败: %v", err)
+	}
+
+	// 旧库升级：补上 source_id，标记每个路径来自哪个已配置的数据源（本地/SMB/WebDAV/S3）
+	if _, err := db.Exec(`ALTER TABLE paths ADD COLUMN source_id TEXT NOT NULL DEFAULT 'local'`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("添加source_id列失败: %v", err)
+	}
+
+	// 旧库升级：files 表补一份 dirpath 冗余列，供下面 files_fts 的 content='files' 外部内容表
+	// 直接读取，不必再跟 paths 表 join
+	if _, err := db.Exec(`ALTER TABLE files ADD COLUMN dirpath TEXT`); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("添加dirpath列失败: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE files SET dirpath = (SELECT path FROM paths WHERE paths.id = files.path_id) WHERE dirpath IS NULL`); err != nil {
+		return fmt.Errorf("回填dirpath列失败: %v", err)
+	}
+
+	// file_bigrams 把文件名的二元分词结果存成 (file_id, gram) 索引表，gram 上建普通 B-tree
+	// 索引，中日文件名查询靠等值查找而不是 LIKE 模糊匹配，避免退化成全表扫描
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS file_bigrams (
+			file_id INTEGER NOT NULL,
+			gram TEXT NOT NULL,
+			FOREIGN KEY(file_id) REFERENCES files(id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_file_bigrams_gram ON file_bigrams(gram);
+		CREATE INDEX IF NOT EXISTS idx_file_bigrams_file ON file_bigrams(file_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建file_bigrams表失败: %v", err)
+	}
+
+	// 旧库升级：file_bigrams 是这一版才新增的表，已入库的文件不会主动补这份索引——
+	// scanSource 对 mtime/size 未变化的文件直接跳过重写，老数据的 bigrams 永远是空的，
+	// CJK 的 fts 查询会一直查不到结果，所以这里对还没有 file_bigrams 行的文件补一遍
+	if err := backfillFileBigrams(db); err != nil {
+		return fmt.Errorf("回填file_bigrams失败: %v", err)
+	}
+
+	// 旧库升级：早期版本的 files_fts 没有用 content='files' 外部内容表，filename/path
+	// 被整份复制进 FTS 影子表造成文本重复存储；检测到旧结构时先整体重建
+	var legacyFTSCount int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='files_fts' AND sql LIKE '%content=%'`).Scan(&legacyFTSCount); err != nil {
+		return fmt.Errorf("检查files_fts结构失败: %v", err)
+	}
+	needsFTSRebuild := legacyFTSCount == 0
+	if needsFTSRebuild {
+		if _, err := db.Exec(`
+			DROP TRIGGER IF EXISTS files_ai;
+			DROP TRIGGER IF EXISTS files_ad;
+			DROP TRIGGER IF EXISTS files_au;
+			DROP TABLE IF EXISTS files_fts;
+		`); err != nil {
+			return fmt.Errorf("清理旧版files_fts失败: %v", err)
+		}
+	}
+
+	// FTS5 全文索引表，content='files' 声明为外部内容表，filename/dirpath 直接引用
+	// files 表本身而不重复存储；tokenize 使用 unicode61 以支持英文前缀/短语匹配，
+	// 中日文件名再靠 file_bigrams 索引表兜底
+	_, err = db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS files_fts USING fts5(
+			filename, dirpath,
+			content = 'files', content_rowid = 'id',
+			tokenize = 'unicode61 remove_diacritics 2'
+		);
+		CREATE TRIGGER IF NOT EXISTS files_ai AFTER INSERT ON files BEGIN
+			INSERT INTO files_fts(rowid, filename, dirpath)
+			VALUES (new.id, new.filename, new.dirpath);
+		END;
+		CREATE TRIGGER IF NOT EXISTS files_ad AFTER DELETE ON files BEGIN
+			INSERT INTO files_fts(files_fts, rowid, filename, dirpath)
+			VALUES ('delete', old.id, old.filename, old.dirpath);
+		END;
+		CREATE TRIGGER IF NOT EXISTS files_au AFTER UPDATE ON files BEGIN
+			INSERT INTO files_fts(files_fts, rowid, filename, dirpath)
+			VALUES ('delete', old.id, old.filename, old.dirpath);
+			INSERT INTO files_fts(rowid, filename, dirpath)
+			VALUES (new.id, new.filename, new.dirpath);
+		END;
+	`)
+	if err != nil {
+		return fmt.Errorf("创建FTS5索引失败: %v", err)
+	}
+	if needsFTSRebuild {
+		if _, err := db.Exec(`INSERT INTO files_fts(files_fts) VALUES('rebuild')`); err != nil {
+			return fmt.Errorf("重建FTS5索引失败: %v", err)
+		}
+	}
+
+	// scans 记录每一轮扫描（覆盖所有数据源）的起止时间和行数，供 /export 的
+	// If-Modified-Since 判断以及后续的任务状态展示使用
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS scans (
+			id INTEGER PRIMARY KEY,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			status TEXT NOT NULL DEFAULT 'running',
+			files_scanned INTEGER NOT NULL DEFAULT 0,
+			files_changed INTEGER NOT NULL DEFAULT 0,
+			error TEXT
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建scans表失败: %v", err)
+	}
+
+	// subscriptions 把旧版写死的单一 time=HH:MM 定时任务，升级成每个数据源可以有自己
+	// cron 表达式的任务订阅，status/last_run/last_duration/last_error 供 /jobs 接口展示
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			id INTEGER PRIMARY KEY,
+			alias TEXT NOT NULL,
+			source_id TEXT NOT NULL,
+			root_path TEXT,
+			cron_expr TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'idle',
+			last_run DATETIME,
+			last_duration INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			log_path TEXT
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建subscriptions表失败: %v", err)
+	}
+
+	// dup_groups/dup_members 保存去重扫描结果：按文件首尾+大小的三段式指纹分组，
+	// dup_members 是分组到 files 行的多对多关联，供 /duplicates 接口按浪费空间排序展示
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS dup_groups (
+			id INTEGER PRIMARY KEY,
+			fingerprint TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			count INTEGER NOT NULL,
+			total_bytes INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS dup_members (
+			group_id INTEGER NOT NULL,
+			file_id INTEGER NOT NULL,
+			FOREIGN KEY(group_id) REFERENCES dup_groups(id),
+			FOREIGN KEY(file_id) REFERENCES files(id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_dup_members_group ON dup_members(group_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("创建去重相关表失败: %v", err)
+	}
+
 	log.Println("数据库初始化完成，表格已创建或已存在")
 	return nil
 }
@@ -82,101 +270,404 @@ func processPath(inputPath string) (string, error) {
 	return filepath.ToSlash(absPath), nil
 }
 
-func scanAndSave(configPath, dbPath string, done chan bool) {
-	log.Println("开始扫描文件...")
-	db, err := sql.Open("sqlite", dbPath)
+// isCJK 判断字符串中是否包含中日韩文字——这类文字 unicode61 分词器按字切分，
+// 前缀/短语匹配效果差，需要 file_bigrams 索引表兜底
+func isCJK(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// computeBigramList 把文件名切成重叠的二元组（2-gram），小写归一化后写入 file_bigrams
+// 索引表；查询时对关键词做同样的切分，再按 gram 等值查找取交集，弥补 unicode61 对中日
+// 文件名分词不佳的问题，且不必像 LIKE '%gram%' 那样全表扫描
+func computeBigramList(s string) []string {
+	runes := []rune(strings.ToLower(s))
+	if len(runes) == 0 {
+		return nil
+	}
+	if len(runes) < 2 {
+		return []string{string(runes)}
+	}
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+// backfillFileBigrams 给还没有 file_bigrams 行的文件补一遍分词，供 initDB 在新增
+// file_bigrams 表时对旧库里已入库的文件做一次性回填
+func backfillFileBigrams(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, filename FROM files WHERE id NOT IN (SELECT file_id FROM file_bigrams)`)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer db.Close()
+	type pendingFile struct {
+		id       int64
+		filename string
+	}
+	var pending []pendingFile
+	for rows.Next() {
+		var f pendingFile
+		if err := rows.Scan(&f.id, &f.filename); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, f)
+	}
+	rows.Close()
 
-	// 开始事务
-	tx, err := db.Begin()
+	for _, f := range pending {
+		for _, gram := range computeBigramList(f.filename) {
+			if _, err := db.Exec("INSERT INTO file_bigrams (file_id, gram) VALUES (?, ?)", f.id, gram); err != nil {
+				return err
+			}
+		}
+	}
+	if len(pending) > 0 {
+		log.Printf("file_bigrams 回填完成，共处理 %d 个文件\n", len(pending))
+	}
+	return nil
+}
+
+// fileSHA1 计算文件内容的 sha1，超过 hashSizeCap 的文件跳过计算（返回空字符串）
+func fileSHA1(path string, size int64) (string, error) {
+	if size > hashSizeCap {
+		return "", nil
+	}
+	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	// 清空表
-	_, err = tx.Exec("DELETE FROM files; DELETE FROM paths")
+// scanSource 对单个 Source 做增量扫描：只有 mtime/size 与库中记录不一致的文件才会被
+// 重新写入并重新计算哈希，扫描期间旧数据仍然可查询；扫描结束后清理已不存在的记录。
+func scanSource(ctx context.Context, src Source, dbPath string) (int64, int64, error) {
+	log.Println("开始增量扫描数据源:", src.ID())
+	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
-		tx.Rollback()
-		log.Fatal(err)
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
 	}
 
 	// 路径缓存
 	pathCache := make(map[string]int64)
-	var totalFiles int64
-	var scannedFiles int64
-
-	// 先遍历一遍，计算文件总数
-	err = filepath.Walk(configPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-		totalFiles++
-		return nil
-	})
+	// seen 记录本次扫描发现的 path_id -> 文件名集合，扫描结束后用于清理已删除的文件/路径
+	seen := make(map[int64]map[string]bool)
+	var scannedFiles, changedFiles int64
+
+	// knownPathIDs 是该数据源在扫描前已登记的所有 path_id，而不仅仅是本次 Walk 触达的目录——
+	// 否则一个目录下的文件全被删除、或目录本身被整个移走时，它不会出现在 pathCache 里，
+	// 其 files/paths 行也就永远不会被下面的清理逻辑访问到
+	knownPathIDs := make(map[int64]bool)
+	knownRows, err := tx.Query("SELECT id FROM paths WHERE source_id = ?", src.ID())
 	if err != nil {
 		tx.Rollback()
-		log.Fatal(err)
+		return scannedFiles, changedFiles, err
 	}
-
-	log.Printf("文件扫描开始，共需要扫描 %d 个文件\n", totalFiles)
-
-	// 执行文件扫描并保存到数据库
-	err = filepath.Walk(configPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
+	for knownRows.Next() {
+		var id int64
+		if err := knownRows.Scan(&id); err != nil {
+			knownRows.Close()
+			tx.Rollback()
+			return scannedFiles, changedFiles, err
 		}
+		knownPathIDs[id] = true
+	}
+	knownRows.Close()
 
-		// 提取路径和文件名
-		dir := filepath.ToSlash(filepath.Dir(path))
-		filename := filepath.Base(path)
+	entries, errs := src.Walk(ctx)
+	for entry := range entries {
+		dir := entry.Dir
+		filename := entry.Name
 
-		// 获取路径 ID
 		pathID, ok := pathCache[dir]
 		if !ok {
-			res := tx.QueryRow("INSERT OR IGNORE INTO paths (path) VALUES (?) RETURNING id", dir)
+			res := tx.QueryRow(
+				"INSERT INTO paths (path, source_id) VALUES (?, ?) ON CONFLICT(path) DO UPDATE SET source_id=excluded.source_id RETURNING id",
+				dir, src.ID(),
+			)
 			if err := res.Scan(&pathID); err != nil {
-				return err
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
 			}
 			pathCache[dir] = pathID
 		}
 
-		// 插入文件记录
-		_, err = tx.Exec(
-			"INSERT INTO files (path_id, filename, size, create_time) VALUES (?, ?, ?, ?)",
+		if seen[pathID] == nil {
+			seen[pathID] = make(map[string]bool)
+		}
+		seen[pathID][filename] = true
+
+		mtime := entry.ModTime.Format(time.RFC3339)
+
+		var existingMtime string
+		var existingSize int64
+		scanErr := tx.QueryRow("SELECT mtime, size FROM files WHERE path_id = ? AND filename = ?", pathID, filename).Scan(&existingMtime, &existingSize)
+		if scanErr == nil && existingMtime == mtime && existingSize == entry.Size {
+			// mtime/size 均未变化，跳过重写
+			scannedFiles++
+			continue
+		}
+
+		// 内容可能变化，惰性计算 sha1（仅本地文件，超出体积上限或非本地数据源时留空）
+		var sum string
+		if local, ok := src.(*localSource); ok {
+			sum, err = fileSHA1(filepath.Join(dir, filename), entry.Size)
+			if err != nil {
+				log.Printf("计算哈希失败 %s/%s: %v\n", local.root, filename, err)
+			}
+		}
+
+		var fileID int64
+		err = tx.QueryRow(
+			`INSERT INTO files (path_id, filename, size, create_time, mtime, sha1, dirpath)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(path_id, filename) DO UPDATE SET
+				size=excluded.size, create_time=excluded.create_time, mtime=excluded.mtime, sha1=excluded.sha1, dirpath=excluded.dirpath
+			 RETURNING id`,
 			pathID,
 			filename,
-			info.Size(),
-			info.ModTime().Format(time.RFC3339),
-		)
+			entry.Size,
+			mtime,
+			mtime,
+			sum,
+			dir,
+		).Scan(&fileID)
 		if err != nil {
-			return err
+			tx.Rollback()
+			return scannedFiles, changedFiles, err
 		}
 
-		// 更新扫描进度，每30秒输出一次
-		scannedFiles++
-		if scannedFiles%1000 == 0 { // 每1000个文件输出一次进度
-			log.Printf("扫描进度: %d/%d 文件已扫描...\n", scannedFiles, totalFiles)
+		// file_bigrams 随文件内容一起重建：先清空该文件的旧分词，再按当前文件名写入
+		if _, err := tx.Exec("DELETE FROM file_bigrams WHERE file_id = ?", fileID); err != nil {
+			tx.Rollback()
+			return scannedFiles, changedFiles, err
 		}
+		for _, gram := range computeBigramList(filename) {
+			if _, err := tx.Exec("INSERT INTO file_bigrams (file_id, gram) VALUES (?, ?)", fileID, gram); err != nil {
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
+			}
+		}
+		changedFiles++
 
-		return nil
-	})
+		scannedFiles++
+		if scannedFiles%1000 == 0 {
+			log.Printf("扫描进度(%s): 已比对 %d 个文件...\n", src.ID(), scannedFiles)
+		}
+	}
 
-	if err != nil {
+	if walkErr := <-errs; walkErr != nil {
 		tx.Rollback()
-		log.Fatal(err)
+		return scannedFiles, changedFiles, walkErr
+	}
+
+	// 清理本次扫描未再出现的文件，而不是整表清空；对象是该数据源已知的全部路径
+	// （knownPathIDs），而不只是 pathCache 里本次 Walk 实际touch到的路径
+	for _, pathID := range pathCache {
+		knownPathIDs[pathID] = true
+	}
+	for pathID := range knownPathIDs {
+		existing, err := tx.Query("SELECT filename FROM files WHERE path_id = ?", pathID)
+		if err != nil {
+			tx.Rollback()
+			return scannedFiles, changedFiles, err
+		}
+		var stale []string
+		for existing.Next() {
+			var filename string
+			if err := existing.Scan(&filename); err != nil {
+				existing.Close()
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
+			}
+			if !seen[pathID][filename] {
+				stale = append(stale, filename)
+			}
+		}
+		existing.Close()
+
+		for _, filename := range stale {
+			if _, err := tx.Exec(
+				"DELETE FROM file_bigrams WHERE file_id IN (SELECT id FROM files WHERE path_id = ? AND filename = ?)",
+				pathID, filename,
+			); err != nil {
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
+			}
+			if _, err := tx.Exec("DELETE FROM files WHERE path_id = ? AND filename = ?", pathID, filename); err != nil {
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
+			}
+		}
+
+		// 目录本身也被整个移走（这次扫描完全没再发现它）时，files 清空后把 paths 行一并删掉
+		if len(seen[pathID]) == 0 {
+			if _, err := tx.Exec("DELETE FROM paths WHERE id = ?", pathID); err != nil {
+				tx.Rollback()
+				return scannedFiles, changedFiles, err
+			}
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Fatal(err)
+		return scannedFiles, changedFiles, err
 	}
 
-	log.Println("文件扫描完成，数据已更新")
+	log.Printf("数据源 %s 扫描完成，共比对 %d 个文件，其中 %d 个有变化并已写入\n", src.ID(), scannedFiles, changedFiles)
+	return scannedFiles, changedFiles, nil
+}
+
+// recordScan 把一次扫描包进 scans 表的起止记录，并在扫描结束后触发一轮重复文件检测；
+// runScan 负责实际执行扫描。首次启动的全量扫描（scanAndSave）、fsnotify 触发的增量扫描
+// （watchPaths）、以及每个任务订阅的定时扫描（jobRunner.run）都要经过这一层，否则只有
+// 首次启动那一次扫描会出现在 scans 表里，/export 的 If-Modified-Since 判断和 /duplicates
+// 的自动更新在已有 sql.db 的升级安装上就永远不会再生效
+func recordScan(dbPath string, runScan func() (int64, int64, error)) (int64, int64, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		log.Println("打开数据库失败:", err)
+		return runScan()
+	}
+
+	startedAt := time.Now().UTC().Format(time.RFC3339)
+	res, err := db.Exec("INSERT INTO scans (started_at, status) VALUES (?, 'running')", startedAt)
+	var scanID int64
+	if err == nil {
+		scanID, _ = res.LastInsertId()
+	}
+	db.Close()
+
+	scanned, changed, scanErr := runScan()
+
+	finalizeDB, err := sql.Open("sqlite", dbPath)
+	if err == nil {
+		status := "ok"
+		errMsg := ""
+		if scanErr != nil {
+			status = "error"
+			errMsg = scanErr.Error()
+		}
+		finalizeDB.Exec(
+			"UPDATE scans SET finished_at = ?, status = ?, files_scanned = ?, files_changed = ?, error = ? WHERE id = ?",
+			time.Now().UTC().Format(time.RFC3339), status, scanned, changed, errMsg, scanID,
+		)
+		finalizeDB.Close()
+	}
+
+	// 重复文件检测是独立的后台 pass，不阻塞本轮扫描收尾；失败只记日志，不影响主索引状态
+	go func() {
+		if err := computeDuplicates(dbPath); err != nil {
+			log.Println("重复文件扫描失败:", err)
+		}
+	}()
+
+	return scanned, changed, scanErr
+}
+
+// scanAndSave 依次对每个已配置的数据源执行增量扫描，交给 recordScan 记录本轮扫描的
+// 起止时间和文件数，供 /export 的 If-Modified-Since 判断使用
+func scanAndSave(sources []Source, dbPath string, done chan bool) {
+	recordScan(dbPath, func() (int64, int64, error) {
+		var totalScanned, totalChanged int64
+		var firstErr error
+		for _, src := range sources {
+			scanned, changed, err := scanSource(context.Background(), src, dbPath)
+			totalScanned += scanned
+			totalChanged += changed
+			if err != nil {
+				log.Printf("数据源 %s 扫描失败: %v\n", src.ID(), err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return totalScanned, totalChanged, firstErr
+	})
+
 	done <- true // 完成扫描
 }
 
+// watchPaths 使用 fsnotify 监听本地数据源 root 下的所有目录，文件发生变化时立即触发一次
+// 针对该数据源的增量扫描，这样查询端不必等待下一次 cron 定时扫描就能看到最新结果。
+// SMB/WebDAV/S3 等远程数据源没有本地文件系统事件可订阅，仍然只能依赖定时扫描。
+func watchPaths(src *localSource, dbPath string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("创建文件监听失败:", err)
+		return
+	}
+
+	err = filepath.Walk(src.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if werr := watcher.Add(path); werr != nil {
+				log.Println("监听目录失败:", path, werr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("遍历待监听目录失败:", err)
+	}
+
+	log.Println("文件系统监听已启动:", src.root)
+
+	// 事件到达后做短暂合并，避免短时间内大量写入触发重复扫描
+	var pending bool
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				if !pending {
+					pending = true
+					debounce.Reset(2 * time.Second)
+				}
+			}
+		case <-debounce.C:
+			pending = false
+			if _, _, err := recordScan(dbPath, func() (int64, int64, error) {
+				return scanSource(context.Background(), src, dbPath)
+			}); err != nil {
+				log.Println("增量扫描失败:", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("文件监听出错:", err)
+		}
+	}
+}
+
 func main() {
 	// 获取可执行文件所在目录
     exePath, err := os.Executable()
@@ -193,9 +684,30 @@ func main() {
         log.Fatal("加载配置文件失败: ", err)
     }
 
-	configPath, err := processPath(os.Getenv("path"))
-	if err != nil {
-		log.Fatal("路径处理错误:", err)
+	// sources.json/sources.yaml 描述多个数据源（本地路径、SMB、WebDAV、S3 桶）；
+	// 没有配置时退回到旧版 config.env 里的单个本地 path 字段，保持向后兼容
+	var sourceConfigs []SourceConfig
+	sourcesPath := os.Getenv("sources")
+	if sourcesPath != "" {
+		sourceConfigs, err = loadSources(filepath.Join(exeDir, sourcesPath))
+		if err != nil {
+			log.Fatal("数据源配置加载失败:", err)
+		}
+	} else {
+		legacyPath, err := processPath(os.Getenv("path"))
+		if err != nil {
+			log.Fatal("路径处理错误:", err)
+		}
+		sourceConfigs = []SourceConfig{{ID: "local", Type: "local", Root: legacyPath}}
+	}
+
+	sources := make([]Source, 0, len(sourceConfigs))
+	for _, cfg := range sourceConfigs {
+		src, err := newSource(cfg)
+		if err != nil {
+			log.Fatal("数据源初始化失败:", err)
+		}
+		sources = append(sources, src)
 	}
 
 	configTime := os.Getenv("time")
@@ -219,7 +731,7 @@ func main() {
 		}
 
 		// 执行文件扫描
-		go scanAndSave(configPath, dbPath, done)
+		go scanAndSave(sources, dbPath, done)
 
 		// 等待扫描完成
 		<-done
@@ -228,6 +740,13 @@ func main() {
 		log.Println("检测到已存在 sql.db 文件，跳过首次扫描")
 	}
 
+	// 启动文件系统监听，实时感知变化，减少对定时扫描的依赖；只有本地数据源支持监听
+	for _, src := range sources {
+		if local, ok := src.(*localSource); ok {
+			go watchPaths(local, dbPath)
+		}
+	}
+
 	timeParts := strings.Split(configTime, ":")
 	if len(timeParts) != 2 {
 		log.Fatal("时间格式应为HH:MM")
@@ -243,27 +762,31 @@ func main() {
 		log.Fatal("无效的分钟数")
 	}
 
-	log.Printf("设置定时任务，每天 %02d:%02d 执行扫描任务\n", hour, minute)
+	legacyCron := fmt.Sprintf("%d %d * * *", minute, hour)
 
-	// 定时任务
-	cronScheduler := cron.New()
-	cronExp := fmt.Sprintf("%d %d * * *", minute, hour)
-	_, err = cronScheduler.AddFunc(cronExp, func() {
-		log.Println("开始定时文件扫描...")
-		done := make(chan bool)
-		go scanAndSave(configPath, dbPath, done)
+	// 首次启动时，把每个数据源按 config.env 里的 time 字段注册成默认任务订阅，
+	// 后续可以通过 /jobs 接口单独调整每个数据源自己的 cron 表达式
+	if err := seedDefaultSubscriptions(dbPath, legacyCron, sourceConfigs); err != nil {
+		log.Fatal("初始化任务订阅失败:", err)
+	}
 
-		// 等待扫描完成
-		<-done
-	})
-	if err != nil {
-		log.Fatal("创建定时任务失败: ", err)
+	jr := newJobRunner(dbPath, exeDir, sources)
+
+	// 定时任务：所有 subscriptions 共用一个 cron 调度器
+	cronScheduler := cron.New()
+	if err := jr.scheduleAll(cronScheduler); err != nil {
+		log.Fatal("加载任务订阅失败:", err)
 	}
 	cronScheduler.Start()
 	defer cronScheduler.Stop()
 
+	// 任务管理 REST 接口：CRUD + 立即执行/中断/查看日志
+	http.HandleFunc("/jobs", handleJobsCollection(dbPath, jr))
+	http.HandleFunc("/jobs/", handleJobItem(dbPath, jr))
+
 	// HTTP 路由
 	http.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
 		key := r.URL.Query().Get("key")
 		queryType := r.URL.Query().Get("type")
 
@@ -272,6 +795,24 @@ func main() {
 			return
 		}
 
+		limit := defaultLimit
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+			limit = v
+		}
+		offset := 0
+		if v, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && v >= 0 {
+			offset = v
+		}
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy == "" {
+			sortBy = "score"
+		}
+		ext := strings.ToLower(r.URL.Query().Get("ext"))
+		fuzz := 0
+		if v, err := strconv.Atoi(r.URL.Query().Get("fuzz")); err == nil && v >= 0 && v <= maxFuzz {
+			fuzz = v
+		}
+
 		db, err := sql.Open("sqlite", dbPath)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -280,41 +821,142 @@ func main() {
 		defer db.Close()
 
 		var query string
+		var args []interface{}
+		withBM25 := false
 		switch queryType {
 		case "file":
-			query = `
-				SELECT p.path, f.filename, f.size, f.create_time
-				FROM files f
-				JOIN paths p ON f.path_id = p.id
-				WHERE f.filename LIKE ?`
+			if fuzz > 0 {
+				// 拼写容错需要逐条比较编辑距离，不能再靠 LIKE 缩小候选集，退而扫描全表
+				query = `
+					SELECT p.path, f.filename, f.size, f.create_time, p.source_id
+					FROM files f
+					JOIN paths p ON f.path_id = p.id`
+			} else {
+				query = `
+					SELECT p.path, f.filename, f.size, f.create_time, p.source_id
+					FROM files f
+					JOIN paths p ON f.path_id = p.id
+					WHERE f.filename LIKE ?`
+				args = []interface{}{"%" + key + "%"}
+			}
+		case "fts":
+			if isCJK(key) {
+				// unicode61 按字切分中日文件名效果差，改用 file_bigrams 索引表按 gram 等值查找，
+				// 多个 gram 之间用 INTERSECT 取交集，避免 LIKE '%gram%' 的全表扫描
+				grams := computeBigramList(key)
+				if len(grams) == 0 {
+					grams = []string{strings.ToLower(key)}
+				}
+				subqueries := make([]string, len(grams))
+				for i, g := range grams {
+					subqueries[i] = "SELECT file_id FROM file_bigrams WHERE gram = ?"
+					args = append(args, g)
+				}
+				query = fmt.Sprintf(`
+					SELECT p.path, f.filename, f.size, f.create_time, p.source_id
+					FROM files f
+					JOIN paths p ON f.path_id = p.id
+					WHERE f.id IN (%s)`, strings.Join(subqueries, " INTERSECT "))
+			} else {
+				withBM25 = true
+				query = `
+					SELECT p.path, f.filename, f.size, f.create_time, p.source_id, bm25(files_fts)
+					FROM files_fts
+					JOIN files f ON files_fts.rowid = f.id
+					JOIN paths p ON f.path_id = p.id
+					WHERE files_fts MATCH ?`
+				args = []interface{}{key}
+			}
 		default:
 			http.Error(w, "无效的查询类型", http.StatusBadRequest)
 			return
 		}
 
-		rows, err := db.Query(query, "%"+key+"%")
+		rows, err := db.Query(query, args...)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var files []FileInfo
+		lowerKey := strings.ToLower(key)
+		hits := make([]SearchHit, 0)
 		for rows.Next() {
 			var fi FileInfo
 			var createTime string
-			if err := rows.Scan(&fi.Path, &fi.FileName, &fi.Size, &createTime); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			var bm25Score float64
+			if withBM25 {
+				if err := rows.Scan(&fi.Path, &fi.FileName, &fi.Size, &createTime, &fi.SourceID, &bm25Score); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			} else {
+				if err := rows.Scan(&fi.Path, &fi.FileName, &fi.Size, &createTime, &fi.SourceID); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
 			}
 			fi.CreateTime = createTime
-			files = append(files, fi)
+
+			if ext != "" && strings.ToLower(strings.TrimPrefix(filepath.Ext(fi.FileName), ".")) != ext {
+				continue
+			}
+
+			stem := strings.TrimSuffix(fi.FileName, filepath.Ext(fi.FileName))
+			var score float64
+			if withBM25 {
+				// bm25 得分越小表示越相关，取负数后分数越大代表相关度越高
+				score -= bm25Score
+			}
+			if fuzz > 0 {
+				dist := fuzzy.Distance(strings.ToLower(stem), lowerKey, fuzz)
+				if dist < 0 {
+					continue
+				}
+				score += float64(fuzz + 1 - dist)
+			}
+			if strings.HasPrefix(strings.ToLower(fi.FileName), lowerKey) {
+				score += 5 // 命中文件名前缀，优先排在前面
+			}
+
+			hits = append(hits, SearchHit{FileInfo: fi, Score: score})
 		}
 
+		switch sortBy {
+		case "name":
+			sort.Slice(hits, func(i, j int) bool { return hits[i].FileName < hits[j].FileName })
+		case "size":
+			sort.Slice(hits, func(i, j int) bool { return hits[i].Size > hits[j].Size })
+		case "mtime":
+			sort.Slice(hits, func(i, j int) bool { return hits[i].CreateTime > hits[j].CreateTime })
+		default: // score
+			sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+		}
+
+		total := len(hits)
+		if offset > total {
+			offset = total
+		}
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+		hits = hits[offset:end]
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(files)
+		json.NewEncoder(w).Encode(SearchResponse{
+			Total:  total,
+			TookMs: time.Since(start).Milliseconds(),
+			Hits:   hits,
+		})
 	})
 
+	// 全量索引的分页导出，供移动端/外部搜索界面/备份校验等下游工具批量拉取
+	http.HandleFunc("/export", handleExport(dbPath, exeDir))
+
+	// 重复文件报告：按 size 分组 + 首尾指纹二次确认，结果按浪费空间降序展示
+	http.HandleFunc("/duplicates", handleDuplicates(dbPath))
+
 	// 创建 CORS 中间件
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"}, // 允许的域名
@@ -0,0 +1,81 @@
+// Package fuzzy 实现搜索用的 Damerau-Levenshtein 编辑距离，支持相邻字符换位，
+// 用于给文件名提供有限的拼写容错（例如把 "photot" 匹配到 "photo"）。
+package fuzzy
+
+// Distance 计算 a、b 之间的 Damerau-Levenshtein 编辑距离（含换位）。只滚动保留换位
+// 回看需要的三行（而不是整张 (n+1)x(m+1) 矩阵），行宽取较短串的长度，内存降到
+// O(min(n,m))；一旦当前行的最小值已经超过 maxDist，后续只会更大，直接返回 -1，
+// 避免把 O(N·k) 退化成 O(N²)。
+func Distance(a, b string, maxDist int) int {
+	ra, rb := []rune(a), []rune(b)
+	n, m := len(ra), len(rb)
+
+	if abs(n-m) > maxDist {
+		return -1
+	}
+
+	if m > n {
+		ra, rb = rb, ra
+		n, m = m, n
+	}
+
+	prev2 := make([]int, m+1)
+	prev1 := make([]int, m+1)
+	cur := make([]int, m+1)
+	for j := 0; j <= m; j++ {
+		prev1[j] = j
+	}
+
+	for i := 1; i <= n; i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= m; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			// cur[j] = min(删除, 插入, 替换, 换位)
+			cur[j] = min3(prev1[j]+1, cur[j-1]+1, prev1[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				if t := prev2[j-2] + 1; t < cur[j] {
+					cur[j] = t
+				}
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > maxDist {
+			return -1
+		}
+		prev2, prev1, cur = prev1, cur, prev2
+	}
+
+	if prev1[m] > maxDist {
+		return -1
+	}
+	return prev1[m]
+}
+
+// Within 是 Distance 的布尔版本，只关心是否落在 maxDist 以内
+func Within(a, b string, maxDist int) bool {
+	return Distance(a, b, maxDist) >= 0
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package fuzzy
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"photo", "photo", 0},
+		{"photo", "photot", 1},  // 插入
+		{"photo", "phot", 1},    // 删除
+		{"photo", "phoro", 1},   // 替换
+		{"ab", "ba", 1},         // 换位
+		{"照片", "照片", 0},
+		{"照片", "片照", 1}, // 中文换位
+		{"kitten", "sitting", 3},
+	}
+
+	for _, c := range cases {
+		if got := Distance(c.a, c.b, len(c.a)+len(c.b)); got != c.want {
+			t.Errorf("Distance(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+// TestDistanceMaxDist 验证超出 maxDist 时按约定返回 -1，而不是真实距离
+func TestDistanceMaxDist(t *testing.T) {
+	if got := Distance("kitten", "sitting", 2); got != -1 {
+		t.Errorf("Distance with maxDist=2 = %d, want -1 (真实距离为3)", got)
+	}
+	if got := Distance("kitten", "sitting", 3); got != 3 {
+		t.Errorf("Distance with maxDist=3 = %d, want 3", got)
+	}
+}
+
+func TestWithin(t *testing.T) {
+	if !Within("photo", "photot", 1) {
+		t.Error("Within(\"photo\", \"photot\", 1) = false, want true")
+	}
+	if Within("photo", "photot", 0) {
+		t.Error("Within(\"photo\", \"photot\", 0) = true, want false")
+	}
+}
+
+// naiveDistance 是未做行滚动优化的参考实现，分配整张 (n+1)x(m+1) 矩阵，用来在随机
+// 字符串上交叉验证 Distance 的滚动行 DP 没有改变语义
+func naiveDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	n, m := len(ra), len(rb)
+
+	d := make([][]int, n+1)
+	for i := range d {
+		d[i] = make([]int, m+1)
+	}
+	for i := 0; i <= n; i++ {
+		d[i][0] = i
+	}
+	for j := 0; j <= m; j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				if t := d[i-2][j-2] + 1; t < d[i][j] {
+					d[i][j] = t
+				}
+			}
+		}
+	}
+	return d[n][m]
+}
+
+// TestDistanceAgainstNaiveReference 用固定种子的随机字符串对比滚动行实现和未优化的
+// 参考实现，确认 Distance 在 maxDist 足够大、不会提前短路时和朴素算法给出相同结果
+func TestDistanceAgainstNaiveReference(t *testing.T) {
+	alphabet := []rune("ab照片xyz")
+	rng := rand.New(rand.NewSource(42))
+
+	randString := func(maxLen int) string {
+		n := rng.Intn(maxLen + 1)
+		rs := make([]rune, n)
+		for i := range rs {
+			rs[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return string(rs)
+	}
+
+	for i := 0; i < 2000; i++ {
+		a := randString(8)
+		b := randString(8)
+		want := naiveDistance(a, b)
+		maxDist := len(a) + len(b) // 足够大，保证不会提前短路返回 -1
+		if got := Distance(a, b, maxDist); got != want {
+			t.Fatalf("Distance(%q, %q, %d) = %d, want %d (naive)", a, b, maxDist, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Entry 描述某个数据源里的单个文件，所有 Source 实现统一返回这个结构，
+// 上层扫描逻辑不必关心文件到底来自本地磁盘、SMB 共享、WebDAV 还是 S3 兼容对象存储
+type Entry struct {
+	Dir     string // 所在目录（不含文件名）
+	Name    string // 文件名
+	Size    int64
+	ModTime time.Time
+}
+
+// Source 是单个扫描数据源的抽象，scanAndSave 只依赖这个接口而不再直接调用 filepath.Walk，
+// 这样新增一种存储类型只需要新增一个实现，不用改动扫描和入库逻辑
+type Source interface {
+	// ID 返回数据源标识，写入 paths.source_id，供 /get 结果区分命中来自哪个 NAS 共享/桶
+	ID() string
+	// Walk 遍历数据源下的所有文件，通过 channel 持续产出 Entry；遍历中途的错误通过 errCh 上报
+	Walk(ctx context.Context) (<-chan Entry, <-chan error)
+	// Stat 返回单个文件的最新信息，供 fsnotify 触发的增量更新使用
+	Stat(path string) (Entry, error)
+}
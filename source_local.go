@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// localSource 是最初就支持的本地挂载点扫描方式，基于 filepath.Walk
+type localSource struct {
+	id   string
+	root string
+}
+
+func newLocalSource(id, root string) *localSource {
+	return &localSource{id: id, root: root}
+}
+
+func (s *localSource) ID() string { return s.id }
+
+func (s *localSource) Walk(ctx context.Context) (<-chan Entry, <-chan error) {
+	entries := make(chan Entry, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+
+		err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case entries <- Entry{
+				Dir:     filepath.ToSlash(filepath.Dir(path)),
+				Name:    filepath.Base(path),
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			}:
+			}
+			return nil
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return entries, errs
+}
+
+func (s *localSource) Stat(path string) (Entry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Dir:     filepath.ToSlash(filepath.Dir(path)),
+		Name:    filepath.Base(path),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Source 扫描一个 S3 兼容对象存储桶，把对象 key 当作“路径+文件名”来索引
+type s3Source struct {
+	id     string
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func newS3Source(cfg SourceConfig) (*s3Source, error) {
+	client, err := minio.New(cfg.Addr, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.Credentials["access_key"], cfg.Credentials["secret_key"], ""),
+		Secure: cfg.Credentials["secure"] == "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Source{id: cfg.ID, client: client, bucket: cfg.Share, prefix: cfg.Root}, nil
+}
+
+func (s *s3Source) ID() string { return s.id }
+
+func (s *s3Source) Walk(ctx context.Context) (<-chan Entry, <-chan error) {
+	entries := make(chan Entry, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+
+		for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+			Prefix:    s.prefix,
+			Recursive: true,
+		}) {
+			if obj.Err != nil {
+				errs <- obj.Err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			case entries <- Entry{
+				Dir:     path.Dir(obj.Key),
+				Name:    path.Base(obj.Key),
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+			}:
+			}
+		}
+	}()
+
+	return entries, errs
+}
+
+func (s *s3Source) Stat(key string) (Entry, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Dir:     path.Dir(key),
+		Name:    path.Base(key),
+		Size:    info.Size,
+		ModTime: info.LastModified,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+
+	"github.com/hirochachacha/go-smb2"
+)
+
+// smbSource 通过 SMB2 协议扫描远程 NAS 共享，凭据来自 SourceConfig.Credentials
+type smbSource struct {
+	id       string
+	addr     string // host:port，默认端口 445
+	share    string
+	user     string
+	password string
+	root     string
+}
+
+func newSMBSource(cfg SourceConfig) *smbSource {
+	return &smbSource{
+		id:       cfg.ID,
+		addr:     cfg.Addr,
+		share:    cfg.Share,
+		user:     cfg.Credentials["user"],
+		password: cfg.Credentials["password"],
+		root:     cfg.Root,
+	}
+}
+
+func (s *smbSource) ID() string { return s.id }
+
+// connect 每次扫描独立建立连接，避免长连接在 NAS 重启/网络抖动后失效
+func (s *smbSource) connect() (*smb2.Share, func(), error) {
+	conn, err := net.Dial("tcp", s.addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("连接SMB服务器失败: %v", err)
+	}
+
+	d := &smb2.Dialer{
+		Initiator: &smb2.NTLMInitiator{
+			User:     s.user,
+			Password: s.password,
+		},
+	}
+
+	session, err := d.Dial(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("SMB会话建立失败: %v", err)
+	}
+
+	share, err := session.Mount(s.share)
+	if err != nil {
+		session.Logoff()
+		conn.Close()
+		return nil, nil, fmt.Errorf("挂载SMB共享失败: %v", err)
+	}
+
+	return share, func() {
+		share.Umount()
+		session.Logoff()
+		conn.Close()
+	}, nil
+}
+
+func (s *smbSource) Walk(ctx context.Context) (<-chan Entry, <-chan error) {
+	entries := make(chan Entry, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+
+		share, closeFn, err := s.connect()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer closeFn()
+
+		var walk func(dir string) error
+		walk = func(dir string) error {
+			infos, err := share.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			for _, info := range infos {
+				full := path.Join(dir, info.Name())
+				if info.IsDir() {
+					if err := walk(full); err != nil {
+						return err
+					}
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case entries <- Entry{
+					Dir:     path.Dir(full),
+					Name:    info.Name(),
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+				}:
+				}
+			}
+			return nil
+		}
+
+		if err := walk(s.root); err != nil {
+			errs <- err
+		}
+	}()
+
+	return entries, errs
+}
+
+func (s *smbSource) Stat(p string) (Entry, error) {
+	share, closeFn, err := s.connect()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer closeFn()
+
+	info, err := share.Stat(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Dir:     path.Dir(p),
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
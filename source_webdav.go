@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// webdavSource 扫描一个 WebDAV 服务暴露的目录树
+type webdavSource struct {
+	id     string
+	client *gowebdav.Client
+	root   string
+}
+
+func newWebDAVSource(cfg SourceConfig) *webdavSource {
+	client := gowebdav.NewClient(cfg.Addr, cfg.Credentials["user"], cfg.Credentials["password"])
+	return &webdavSource{id: cfg.ID, client: client, root: cfg.Root}
+}
+
+func (s *webdavSource) ID() string { return s.id }
+
+func (s *webdavSource) Walk(ctx context.Context) (<-chan Entry, <-chan error) {
+	entries := make(chan Entry, 100)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(entries)
+		defer close(errs)
+
+		var walk func(dir string) error
+		walk = func(dir string) error {
+			infos, err := s.client.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			for _, info := range infos {
+				full := path.Join(dir, info.Name())
+				if info.IsDir() {
+					if err := walk(full); err != nil {
+						return err
+					}
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case entries <- Entry{
+					Dir:     path.Dir(full),
+					Name:    info.Name(),
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+				}:
+				}
+			}
+			return nil
+		}
+
+		if err := walk(s.root); err != nil {
+			errs <- err
+		}
+	}()
+
+	return entries, errs
+}
+
+func (s *webdavSource) Stat(p string) (Entry, error) {
+	info, err := s.client.Stat(p)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Dir:     path.Dir(p),
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}